@@ -0,0 +1,50 @@
+package acme
+
+import (
+	"fmt"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/sirupsen/logrus"
+
+	kubelego "github.com/harborfront/kube-lego/pkg/kubelego_const"
+)
+
+type Acme struct {
+	kubelego kubelego.KubeLego
+	storage  AccountStorage
+}
+
+func New(kl kubelego.KubeLego) (*Acme, error) {
+	storage, err := newAccountStorage(kl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Acme{
+		kubelego: kl,
+		storage:  storage,
+	}, nil
+}
+
+func newAccountStorage(kl kubelego.KubeLego) (AccountStorage, error) {
+	switch kl.AcmeAccountStorageBackend() {
+
+	case kubelego.AccountStorageBackendFilesystem:
+		return NewFilesystemAccountStorage(kl.AcmeAccountStorageDir()), nil
+
+	case kubelego.AccountStorageBackendVault:
+		client, err := vault.NewClient(&vault.Config{Address: kl.AcmeVaultAddress()})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Vault client: %s", err)
+		}
+		client.SetToken(kl.AcmeVaultToken())
+		return NewVaultAccountStorage(client, kl.AcmeVaultMountPath(), kl.AcmeVaultPathPrefix()), nil
+
+	default:
+		return NewKubernetesAccountStorage(kl), nil
+	}
+}
+
+func (a *Acme) Log() *logrus.Entry {
+	return a.kubelego.Log()
+}
@@ -0,0 +1,23 @@
+package acme
+
+import (
+	"github.com/go-acme/lego/v4/challenge"
+)
+
+// http01Provider adapts kube-lego's http-01 solver to lego's
+// challenge.Provider interface.
+type http01Provider struct {
+	a *Acme
+}
+
+func (a *Acme) newHTTP01Provider() challenge.Provider {
+	return &http01Provider{a: a}
+}
+
+func (p *http01Provider) Present(domain, token, keyAuth string) error {
+	return p.a.kubelego.SaveAcmeHttp01Status(domain, token, keyAuth)
+}
+
+func (p *http01Provider) CleanUp(domain, token, keyAuth string) error {
+	return p.a.kubelego.DeleteAcmeHttp01Status(domain, token, keyAuth)
+}
@@ -0,0 +1,161 @@
+package acme
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// keystoreV3 is an Ethereum-v3-style JSON envelope: an scrypt-derived key
+// wraps the PEM-encoded account key with AES-128-GCM.
+type keystoreV3 struct {
+	Version int            `json:"version"`
+	Crypto  keystoreCrypto `json:"crypto"`
+}
+
+type keystoreCrypto struct {
+	Cipher       string               `json:"cipher"`
+	CipherText   string               `json:"ciphertext"`
+	CipherParams keystoreCipherParams `json:"cipherparams"`
+	KDF          string               `json:"kdf"`
+	KDFParams    keystoreKDFParams    `json:"kdfparams"`
+	MAC          string               `json:"mac"`
+}
+
+type keystoreCipherParams struct {
+	IV string `json:"iv"`
+}
+
+type keystoreKDFParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Salt  string `json:"salt"`
+	DKLen int    `json:"dklen"`
+}
+
+const (
+	keystoreScryptN     = 262144
+	keystoreScryptR     = 8
+	keystoreScryptP     = 1
+	keystoreScryptDKLen = 32
+)
+
+func isKeystoreEnvelope(data []byte) bool {
+	return strings.HasPrefix(strings.TrimSpace(string(data)), "{")
+}
+
+func encryptPrivateKeyPEM(pemBytes []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, keystoreScryptN, keystoreScryptR, keystoreScryptP, keystoreScryptDKLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key encryption key: %s", err)
+	}
+	encryptionKey := derivedKey[:16]
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	ciphertext := gcm.Seal(nil, iv, pemBytes, nil)
+
+	mac := sha256.Sum256(append(derivedKey[16:32], ciphertext...))
+
+	envelope := keystoreV3{
+		Version: 3,
+		Crypto: keystoreCrypto{
+			Cipher:     "aes-128-gcm",
+			CipherText: hex.EncodeToString(ciphertext),
+			CipherParams: keystoreCipherParams{
+				IV: hex.EncodeToString(iv),
+			},
+			KDF: "scrypt",
+			KDFParams: keystoreKDFParams{
+				N:     keystoreScryptN,
+				R:     keystoreScryptR,
+				P:     keystoreScryptP,
+				Salt:  hex.EncodeToString(salt),
+				DKLen: keystoreScryptDKLen,
+			},
+			MAC: hex.EncodeToString(mac[:]),
+		},
+	}
+
+	return json.Marshal(envelope)
+}
+
+func decryptPrivateKeyPEM(data []byte, passphrase string) ([]byte, error) {
+	envelope := keystoreV3{}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted account key: %s", err)
+	}
+	if envelope.Crypto.Cipher != "aes-128-gcm" || envelope.Crypto.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported keystore cipher '%s' / kdf '%s'", envelope.Crypto.Cipher, envelope.Crypto.KDF)
+	}
+
+	salt, err := hex.DecodeString(envelope.Crypto.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("malformed keystore salt: %s", err)
+	}
+	iv, err := hex.DecodeString(envelope.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("malformed keystore iv: %s", err)
+	}
+	ciphertext, err := hex.DecodeString(envelope.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("malformed keystore ciphertext: %s", err)
+	}
+	wantMAC, err := hex.DecodeString(envelope.Crypto.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("malformed keystore mac: %s", err)
+	}
+
+	kdfParams := envelope.Crypto.KDFParams
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, kdfParams.N, kdfParams.R, kdfParams.P, kdfParams.DKLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key encryption key: %s", err)
+	}
+
+	gotMAC := sha256.Sum256(append(derivedKey[16:32], ciphertext...))
+	if subtle.ConstantTimeCompare(gotMAC[:], wantMAC) != 1 {
+		return nil, fmt.Errorf("could not decrypt ACME account key: wrong passphrase or corrupted keystore")
+	}
+
+	block, err := aes.NewCipher(derivedKey[:16])
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	pemBytes, err := gcm.Open(nil, iv, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt ACME account key: %s", err)
+	}
+
+	return pemBytes, nil
+}
@@ -0,0 +1,256 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// acmeDirectory is the subset of the ACME directory document needed for a
+// key rollover (RFC 8555 §7.3.5), which lego's client doesn't expose.
+type acmeDirectory struct {
+	NewNonce  string `json:"newNonce"`
+	KeyChange string `json:"keyChange"`
+}
+
+type staticNonceSource string
+
+func (n staticNonceSource) Nonce() (string, error) {
+	return string(n), nil
+}
+
+func jwsAlgorithm(key crypto.Signer) (jose.SignatureAlgorithm, error) {
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return jose.RS256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		default:
+			return "", fmt.Errorf("unsupported ECDSA curve '%s' for ACME account key", pub.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		return jose.EdDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported ACME account key type %T", pub)
+	}
+}
+
+func (a *Acme) fetchDirectory(ctx context.Context, directoryURL string) (*acmeDirectory, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, directoryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ACME directory '%s': %s", directoryURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := &acmeDirectory{}
+	if err := json.Unmarshal(body, dir); err != nil {
+		return nil, fmt.Errorf("failed to parse ACME directory '%s': %s", directoryURL, err)
+	}
+
+	return dir, nil
+}
+
+func (a *Acme) fetchNonce(ctx context.Context, newNonceURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, newNonceURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch replay nonce from '%s': %s", newNonceURL, err)
+	}
+	defer resp.Body.Close()
+
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", fmt.Errorf("no Replay-Nonce header returned by '%s'", newNonceURL)
+	}
+
+	return nonce, nil
+}
+
+// RotateExpiredAccountKeys rotates every stored account whose key is older
+// than LEGO_KEY_MAX_AGE, or for which rotation was requested on demand via
+// the kube-lego config annotation. Accounts left alone on this pass drop the
+// previous key kept from an earlier rotation.
+func (a *Acme) RotateExpiredAccountKeys(ctx context.Context) error {
+	directories, err := a.storage.List()
+	if err != nil {
+		return err
+	}
+
+	for _, directoryURL := range directories {
+		acc, err := a.storage.Load(directoryURL)
+		if err != nil {
+			return err
+		}
+		if !a.accountKeyNeedsRotation(directoryURL, acc.KeyCreatedAt) {
+			if acc.PreviousPrivateKeyPEM != "" {
+				acc.PreviousPrivateKeyPEM = ""
+				if err := a.storage.Save(directoryURL, acc); err != nil {
+					return fmt.Errorf("failed to drop previous ACME account key for '%s': %s", directoryURL, err)
+				}
+			}
+			continue
+		}
+		if err := a.RotateAccountKey(ctx, directoryURL); err != nil {
+			return fmt.Errorf("failed to rotate ACME account key for '%s': %s", directoryURL, err)
+		}
+	}
+
+	return nil
+}
+
+// RotateAccountKey performs an RFC 8555 §7.3.5 key rollover for the account
+// registered against directoryURL.
+func (a *Acme) RotateAccountKey(ctx context.Context, directoryURL string) error {
+	acc, err := a.storage.Load(directoryURL)
+	if err != nil {
+		return err
+	}
+	oldKeyPem := acc.PrivateKeyPEM
+
+	_, user, err := a.getUser(directoryURL)
+	if err != nil {
+		return err
+	}
+	oldKey, ok := user.GetPrivateKey().(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("stored ACME account key does not implement crypto.Signer")
+	}
+
+	newKeyPem, newKey, err := a.generatePrivateKey()
+	if err != nil {
+		return err
+	}
+
+	dir, err := a.fetchDirectory(ctx, directoryURL)
+	if err != nil {
+		return err
+	}
+	nonce, err := a.fetchNonce(ctx, dir.NewNonce)
+	if err != nil {
+		return err
+	}
+
+	innerPayload, err := json.Marshal(struct {
+		Account string           `json:"account"`
+		OldKey  *jose.JSONWebKey `json:"oldKey"`
+	}{
+		Account: user.Registration.URI,
+		OldKey:  &jose.JSONWebKey{Key: oldKey.Public()},
+	})
+	if err != nil {
+		return err
+	}
+
+	newKeyAlg, err := jwsAlgorithm(newKey)
+	if err != nil {
+		return err
+	}
+	innerSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: newKeyAlg, Key: newKey}, &jose.SignerOptions{
+		ExtraHeaders: map[jose.HeaderKey]interface{}{"url": dir.KeyChange},
+		EmbedJWK:     true,
+	})
+	if err != nil {
+		return err
+	}
+	innerJWS, err := innerSigner.Sign(innerPayload)
+	if err != nil {
+		return err
+	}
+	innerSerialized := innerJWS.FullSerialize()
+
+	oldKeyAlg, err := jwsAlgorithm(oldKey)
+	if err != nil {
+		return err
+	}
+	outerSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: oldKeyAlg, Key: oldKey}, &jose.SignerOptions{
+		NonceSource: staticNonceSource(nonce),
+		ExtraHeaders: map[jose.HeaderKey]interface{}{
+			"url": dir.KeyChange,
+			"kid": user.Registration.URI,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	outerJWS, err := outerSigner.Sign([]byte(innerSerialized))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dir.KeyChange, strings.NewReader(outerJWS.FullSerialize()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/jose+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post key-change request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("CA rejected key rollover (status %d): %s", resp.StatusCode, body)
+	}
+
+	regBody, err := json.Marshal(user.Registration.Body)
+	if err != nil {
+		return err
+	}
+
+	err = a.storage.Save(directoryURL, &StoredAccount{
+		Email: user.Email,
+		Registration: StoredRegistration{
+			URI:  user.Registration.URI,
+			Body: regBody,
+		},
+		PrivateKeyPEM:         string(newKeyPem),
+		PreviousPrivateKeyPEM: oldKeyPem,
+		EabKeyID:              acc.EabKeyID,
+		KeyCreatedAt:          time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("key rollover succeeded at the CA but failed to persist locally: %s", err)
+	}
+
+	a.Log().Infof("rotated ACME account key for '%s' (registration url: %s)", directoryURL, user.Registration.URI)
+
+	return nil
+}
+
+func (a *Acme) accountKeyNeedsRotation(directoryURL string, keyCreatedAt time.Time) bool {
+	if a.kubelego.AcmeKeyRotationRequested() {
+		return true
+	}
+	return time.Since(keyCreatedAt) > a.kubelego.LegoKeyMaxAge()
+}
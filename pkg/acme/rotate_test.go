@@ -0,0 +1,103 @@
+//go:build integration
+
+package acme
+
+import (
+	"context"
+	"crypto"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	kubelego "github.com/harborfront/kube-lego/pkg/kubelego_const"
+)
+
+// fakeKubeLego implements just enough of kubelego.KubeLego to drive the
+// ACME account lifecycle under test. It embeds the interface so any method
+// this test doesn't need panics loudly instead of silently misbehaving.
+type fakeKubeLego struct {
+	kubelego.KubeLego
+	directoryURL string
+}
+
+func (f *fakeKubeLego) LegoEmail() string              { return "acme-rotation-test@example.com" }
+func (f *fakeKubeLego) LegoURL() string                { return f.directoryURL }
+func (f *fakeKubeLego) LegoKeyType() string             { return kubelego.KeyTypeRsa }
+func (f *fakeKubeLego) LegoKeySize() int                { return 2048 }
+func (f *fakeKubeLego) LegoEabKeyID() string            { return "" }
+func (f *fakeKubeLego) LegoEabHmacKey() string          { return "" }
+func (f *fakeKubeLego) LegoKeyPassphrase() string       { return "" }
+func (f *fakeKubeLego) AcmeKeyRotationRequested() bool  { return false }
+func (f *fakeKubeLego) LegoKeyMaxAge() time.Duration    { return 90 * 24 * time.Hour }
+func (f *fakeKubeLego) Log() *logrus.Entry              { return logrus.NewEntry(logrus.New()) }
+
+// TestRotateAccountKeyAgainstPebble registers a fresh account against a
+// local Pebble instance, rotates its key, and verifies the registration URI
+// survives the rollover while the old key is no longer accepted.
+//
+// Run against Pebble (https://github.com/letsencrypt/pebble):
+//
+//	PEBBLE_DIR_URL=https://localhost:14000/dir go test -tags integration ./pkg/acme/...
+func TestRotateAccountKeyAgainstPebble(t *testing.T) {
+	directoryURL := os.Getenv("PEBBLE_DIR_URL")
+	if directoryURL == "" {
+		t.Skip("PEBBLE_DIR_URL not set, skipping Pebble integration test")
+	}
+
+	kl := &fakeKubeLego{directoryURL: directoryURL}
+	a := &Acme{kubelego: kl, storage: NewFilesystemAccountStorage(t.TempDir())}
+
+	_, user, err := a.createUser(directoryURL)
+	if err != nil {
+		t.Fatalf("createUser: %s", err)
+	}
+	accountURI := user.Registration.URI
+	oldKey, ok := user.GetPrivateKey().(crypto.Signer)
+	if !ok {
+		t.Fatalf("account key does not implement crypto.Signer")
+	}
+
+	if err := a.RotateAccountKey(context.Background(), directoryURL); err != nil {
+		t.Fatalf("RotateAccountKey: %s", err)
+	}
+
+	_, rotatedUser, err := a.getUser(directoryURL)
+	if err != nil {
+		t.Fatalf("getUser after rotation: %s", err)
+	}
+	if rotatedUser.Registration.URI != accountURI {
+		t.Fatalf("account URI changed after rotation: got %q, want %q", rotatedUser.Registration.URI, accountURI)
+	}
+
+	rotatedKey, ok := rotatedUser.GetPrivateKey().(crypto.Signer)
+	if !ok {
+		t.Fatalf("rotated account key does not implement crypto.Signer")
+	}
+	if keysEqual(oldKey.Public(), rotatedKey.Public()) {
+		t.Fatalf("account key did not change after rotation")
+	}
+
+	// The CA must reject the old key: a client still signed by it should
+	// no longer be able to query the account.
+	oldUser := &AcmeUser{Email: user.Email, Registration: user.Registration, key: oldKey}
+	oldClient, err := a.newClient(directoryURL, oldUser)
+	if err != nil {
+		t.Fatalf("newClient with old key: %s", err)
+	}
+	if _, err := oldClient.Registration.QueryRegistration(); err == nil {
+		t.Fatalf("expected the CA to reject the rotated-out account key, but it was accepted")
+	}
+}
+
+func keysEqual(a, b crypto.PublicKey) bool {
+	type equaler interface {
+		Equal(crypto.PublicKey) bool
+	}
+	ea, ok := a.(equaler)
+	if !ok {
+		return false
+	}
+	return ea.Equal(b)
+}
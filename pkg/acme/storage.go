@@ -0,0 +1,44 @@
+package acme
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// StoredRegistration is the ACME registration resource portion of a
+// StoredAccount.
+type StoredRegistration struct {
+	URI  string          `json:"uri"`
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// StoredAccount mirrors lego's own account.json shape, so accounts written
+// by kube-lego can be inspected, or reused, with the lego CLI.
+type StoredAccount struct {
+	Email         string             `json:"email"`
+	Registration  StoredRegistration `json:"registration"`
+	PrivateKeyPEM string             `json:"privateKeyPem"`
+
+	// PreviousPrivateKeyPEM retains the key RotateAccountKey replaced, for
+	// one reconcile cycle (the next RotateExpiredAccountKeys pass that does
+	// not rotate the account clears it), so a failed rollover can be rolled
+	// back.
+	PreviousPrivateKeyPEM string `json:"previousPrivateKeyPem,omitempty"`
+
+	// EabKeyID is the External Account Binding kid the account was
+	// registered with, if any, so re-registration after secret loss
+	// against an EAB-only CA is deterministic.
+	EabKeyID string `json:"eabKeyId,omitempty"`
+
+	// KeyCreatedAt is when PrivateKeyPEM was generated, so
+	// accountKeyNeedsRotation can enforce LEGO_KEY_MAX_AGE.
+	KeyCreatedAt time.Time `json:"keyCreatedAt"`
+}
+
+// AccountStorage persists ACME account credentials keyed by ACME directory
+// URL, so Acme isn't tied to any one backend.
+type AccountStorage interface {
+	Load(directoryURL string) (*StoredAccount, error)
+	Save(directoryURL string, acc *StoredAccount) error
+	List() ([]string, error)
+}
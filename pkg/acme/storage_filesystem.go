@@ -0,0 +1,115 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// filesystemAccountStorage lays accounts out the way lego's own CLI does:
+// accounts/<server>/<email>/account.json plus keys/<email>.key.
+type filesystemAccountStorage struct {
+	baseDir string
+}
+
+func NewFilesystemAccountStorage(baseDir string) AccountStorage {
+	return &filesystemAccountStorage{baseDir: baseDir}
+}
+
+var sanitizeServerRe = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+func sanitizeServer(directoryURL string) string {
+	return sanitizeServerRe.ReplaceAllString(directoryURL, "_")
+}
+
+func (s *filesystemAccountStorage) accountDir(directoryURL, email string) string {
+	return filepath.Join(s.baseDir, "accounts", sanitizeServer(directoryURL), email)
+}
+
+func (s *filesystemAccountStorage) directoryMarkerPath(dir string) string {
+	return filepath.Join(dir, "directory-url")
+}
+
+func (s *filesystemAccountStorage) Load(directoryURL string) (*StoredAccount, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(s.baseDir, "accounts", sanitizeServer(directoryURL)))
+	if err != nil {
+		return nil, fmt.Errorf("no stored ACME account found for directory '%s': %s", directoryURL, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		accountJSON, err := ioutil.ReadFile(filepath.Join(s.baseDir, "accounts", sanitizeServer(directoryURL), entry.Name(), "account.json"))
+		if err != nil {
+			continue
+		}
+		acc := &StoredAccount{}
+		if err := json.Unmarshal(accountJSON, acc); err != nil {
+			return nil, fmt.Errorf("failed to parse account.json for '%s': %s", entry.Name(), err)
+		}
+		return acc, nil
+	}
+
+	return nil, fmt.Errorf("no stored ACME account found for directory '%s'", directoryURL)
+}
+
+func (s *filesystemAccountStorage) Save(directoryURL string, acc *StoredAccount) error {
+	dir := s.accountDir(directoryURL, acc.Email)
+
+	if err := os.MkdirAll(filepath.Join(dir, "keys"), 0700); err != nil {
+		return err
+	}
+
+	accountJSON, err := json.MarshalIndent(acc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "account.json"), accountJSON, 0600); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "keys", acc.Email+".key"), []byte(acc.PrivateKeyPEM), 0600); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.directoryMarkerPath(dir), []byte(directoryURL), 0600)
+}
+
+func (s *filesystemAccountStorage) List() ([]string, error) {
+	serverDirs, err := ioutil.ReadDir(filepath.Join(s.baseDir, "accounts"))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var directories []string
+	for _, serverDir := range serverDirs {
+		if !serverDir.IsDir() {
+			continue
+		}
+		emailDirs, err := ioutil.ReadDir(filepath.Join(s.baseDir, "accounts", serverDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, emailDir := range emailDirs {
+			if !emailDir.IsDir() {
+				continue
+			}
+			marker := s.directoryMarkerPath(filepath.Join(s.baseDir, "accounts", serverDir.Name(), emailDir.Name()))
+			directoryURL, err := ioutil.ReadFile(marker)
+			if err != nil {
+				continue
+			}
+			directories = append(directories, string(directoryURL))
+		}
+	}
+
+	return directories, nil
+}
@@ -0,0 +1,56 @@
+package acme
+
+import (
+	"encoding/json"
+	"fmt"
+
+	kubelego "github.com/harborfront/kube-lego/pkg/kubelego_const"
+)
+
+// kubernetesAccountStorage backs onto the Kubernetes Secret kube-lego
+// already manages.
+type kubernetesAccountStorage struct {
+	kubelego kubelego.KubeLego
+}
+
+func NewKubernetesAccountStorage(kl kubelego.KubeLego) AccountStorage {
+	return &kubernetesAccountStorage{kubelego: kl}
+}
+
+func (s *kubernetesAccountStorage) Load(directoryURL string) (*StoredAccount, error) {
+	data, err := s.kubelego.AcmeUser(accountKey(directoryURL))
+	if err != nil {
+		return nil, err
+	}
+
+	accJSON, ok := data[kubelego.AcmeAccount]
+	if !ok {
+		return nil, fmt.Errorf("no stored ACME account found for directory '%s'", directoryURL)
+	}
+
+	acc := &StoredAccount{}
+	if err := json.Unmarshal(accJSON, acc); err != nil {
+		return nil, fmt.Errorf("failed to parse stored ACME account for directory '%s': %s", directoryURL, err)
+	}
+
+	return acc, nil
+}
+
+func (s *kubernetesAccountStorage) Save(directoryURL string, acc *StoredAccount) error {
+	accJSON, err := json.Marshal(acc)
+	if err != nil {
+		return err
+	}
+
+	return s.kubelego.SaveAcmeUser(
+		accountKey(directoryURL),
+		map[string][]byte{
+			kubelego.AcmeAccount:      accJSON,
+			kubelego.AcmeDirectoryUrl: []byte(directoryURL),
+		},
+	)
+}
+
+func (s *kubernetesAccountStorage) List() ([]string, error) {
+	return s.kubelego.ListAcmeAccountDirectories()
+}
@@ -0,0 +1,89 @@
+package acme
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// vaultAccountStorage stores each account as a JSON blob in a Vault KV v2
+// engine under <prefix>/<accountKey(directoryURL)>.
+type vaultAccountStorage struct {
+	client    *vault.Client
+	mountPath string
+	prefix    string
+}
+
+func NewVaultAccountStorage(client *vault.Client, mountPath, prefix string) AccountStorage {
+	return &vaultAccountStorage{client: client, mountPath: mountPath, prefix: prefix}
+}
+
+func (s *vaultAccountStorage) path(directoryURL string) string {
+	return fmt.Sprintf("%s/%s", s.prefix, accountKey(directoryURL))
+}
+
+func (s *vaultAccountStorage) Load(directoryURL string) (*StoredAccount, error) {
+	secret, err := s.client.KVv2(s.mountPath).Get(context.Background(), s.path(directoryURL))
+	if err != nil {
+		return nil, fmt.Errorf("no stored ACME account found for directory '%s': %s", directoryURL, err)
+	}
+
+	accountJSON, ok := secret.Data["account"].(string)
+	if !ok {
+		return nil, fmt.Errorf("malformed Vault secret at '%s': missing 'account' field", s.path(directoryURL))
+	}
+
+	acc := &StoredAccount{}
+	if err := json.Unmarshal([]byte(accountJSON), acc); err != nil {
+		return nil, fmt.Errorf("failed to parse stored ACME account for directory '%s': %s", directoryURL, err)
+	}
+
+	return acc, nil
+}
+
+func (s *vaultAccountStorage) Save(directoryURL string, acc *StoredAccount) error {
+	accountJSON, err := json.Marshal(acc)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.KVv2(s.mountPath).Put(context.Background(), s.path(directoryURL), map[string]interface{}{
+		"account":      string(accountJSON),
+		"directoryUrl": directoryURL,
+	})
+	return err
+}
+
+func (s *vaultAccountStorage) List() ([]string, error) {
+	listSecret, err := s.client.Logical().List(fmt.Sprintf("%s/metadata/%s", s.mountPath, s.prefix))
+	if err != nil {
+		return nil, err
+	}
+	if listSecret == nil || listSecret.Data == nil {
+		return []string{}, nil
+	}
+
+	keys, ok := listSecret.Data["keys"].([]interface{})
+	if !ok {
+		return []string{}, nil
+	}
+
+	var directories []string
+	for _, key := range keys {
+		name, ok := key.(string)
+		if !ok {
+			continue
+		}
+		secret, err := s.client.KVv2(s.mountPath).Get(context.Background(), fmt.Sprintf("%s/%s", s.prefix, name))
+		if err != nil {
+			continue
+		}
+		if directoryURL, ok := secret.Data["directoryUrl"].(string); ok {
+			directories = append(directories, directoryURL)
+		}
+	}
+
+	return directories, nil
+}
@@ -3,131 +3,258 @@ package acme
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
-	"golang.org/x/crypto/acme"
-	"golang.org/x/net/context"
+	lacme "github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
 
 	kubelego "github.com/harborfront/kube-lego/pkg/kubelego_const"
 )
 
+// AcmeUser implements registration.User.
+type AcmeUser struct {
+	Email        string
+	Registration *registration.Resource
+	key          crypto.PrivateKey
+}
+
+func (u *AcmeUser) GetEmail() string {
+	return u.Email
+}
+
+func (u *AcmeUser) GetRegistration() *registration.Resource {
+	return u.Registration
+}
+
+func (u *AcmeUser) GetPrivateKey() crypto.PrivateKey {
+	return u.key
+}
+
 func (a *Acme) getContact() []string {
 	return []string{
 		fmt.Sprintf("mailto:%s", strings.ToLower(a.kubelego.LegoEmail())),
 	}
 }
 
-func (a *Acme) acceptTos(tos string) bool {
-	a.Log().Infof("if you don't accept the TOS (%s) please exit the program now", tos)
-	return true
+// accountKey keys stored credentials by directory URL, so separate issuers
+// (Let's Encrypt prod/staging, Buypass, ZeroSSL, an internal step-ca, ...)
+// don't collide with one another.
+func accountKey(directoryURL string) string {
+	sum := sha256.Sum256([]byte(directoryURL))
+	return hex.EncodeToString(sum[:])
 }
 
-func (a *Acme) createUser() (client *acme.Client, account *acme.Account, err error) {
+func (a *Acme) newClient(directoryURL string, user *AcmeUser) (*lego.Client, error) {
+	config := lego.NewConfig(user)
+	config.CADirURL = directoryURL
+
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ACME client for '%s': %s", directoryURL, err)
+	}
+
+	err = client.Challenge.SetHTTP01Provider(a.newHTTP01Provider())
+	if err != nil {
+		return nil, fmt.Errorf("failed to register http-01 provider: %s", err)
+	}
+
+	return client, nil
+}
+
+func (a *Acme) createUser(directoryURL string) (client *lego.Client, user *AcmeUser, err error) {
 	privateKeyPem, privateKey, err := a.generatePrivateKey()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	client = &acme.Client{
-		Key:          privateKey,
-		DirectoryURL: a.kubelego.LegoURL(),
+	user = &AcmeUser{
+		Email: a.kubelego.LegoEmail(),
+		key:   privateKey,
+	}
+
+	client, err = a.newClient(directoryURL, user)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	account = &acme.Account{
-		Contact: a.getContact(),
+	eabKeyID := a.kubelego.LegoEabKeyID()
+	eabHmacKey := a.kubelego.LegoEabHmacKey()
+
+	var reg *registration.Resource
+	var usedEabKeyID string
+
+	if eabKeyID != "" && eabHmacKey != "" {
+		reg, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+			TermsOfServiceAgreed: true,
+			Kid:                  eabKeyID,
+			HmacEncoded:          eabHmacKey,
+		})
+		if err != nil {
+			var probDetails *lacme.ProblemDetails
+			if errors.As(err, &probDetails) && probDetails.Type == "urn:ietf:params:acme:error:externalAccountRequired" {
+				return nil, nil, fmt.Errorf("CA '%s' requires external account binding, but registration was rejected: %s", directoryURL, err)
+			}
+			return nil, nil, err
+		}
+		usedEabKeyID = eabKeyID
+	} else {
+		reg, err = client.Registration.Register(registration.RegisterOptions{
+			TermsOfServiceAgreed: true,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	account, err = client.Register(
-		context.Background(),
-		account,
-		a.acceptTos,
-	)
+	user.Registration = reg
+	a.Log().Infof("created an ACME account with '%s' (registration url: %s)", directoryURL, reg.URI)
+
+	regBody, err := json.Marshal(reg.Body)
 	if err != nil {
 		return nil, nil, err
 	}
-	a.Log().Infof("created an ACME account (registration url: %s)", account.URI)
 
-	err = a.kubelego.SaveAcmeUser(
-		map[string][]byte{
-			kubelego.AcmePrivateKey:      privateKeyPem,
-			kubelego.AcmeRegistrationUrl: []byte(account.URI),
+	err = a.storage.Save(directoryURL, &StoredAccount{
+		Email: user.Email,
+		Registration: StoredRegistration{
+			URI:  reg.URI,
+			Body: regBody,
 		},
-	)
+		PrivateKeyPEM: string(privateKeyPem),
+		EabKeyID:      usedEabKeyID,
+		KeyCreatedAt:  time.Now(),
+	})
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return client, account, err
+	return client, user, err
 }
 
-func (a *Acme) getUser() (client *acme.Client, accountURI string, err error) {
+func (a *Acme) getUser(directoryURL string) (client *lego.Client, user *AcmeUser, err error) {
 
-	userData, err := a.kubelego.AcmeUser()
+	acc, err := a.storage.Load(directoryURL)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	privateKeyData, ok := userData[kubelego.AcmePrivateKey]
-	if !ok {
-		return nil, "", fmt.Errorf("could not find acme private key with key '%s'", kubelego.AcmePrivateKey)
+	privateKeyData := []byte(acc.PrivateKeyPEM)
+	if isKeystoreEnvelope(privateKeyData) {
+		privateKeyData, err = decryptPrivateKeyPEM(privateKeyData, a.kubelego.LegoKeyPassphrase())
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+
 	block, _ := pem.Decode(privateKeyData)
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, "", err
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM block found in stored ACME account key for directory '%s'", directoryURL)
 	}
-	client = &acme.Client{
-		Key:          privateKey,
-		DirectoryURL: a.kubelego.LegoURL(),
+	privateKey, err := parseAccountPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	accountURIBytes, ok := userData[kubelego.AcmeRegistrationUrl]
-	if ok {
-		return client, string(accountURIBytes), nil
+	reg := &registration.Resource{URI: acc.Registration.URI}
+	if len(acc.Registration.Body) > 0 {
+		if err := json.Unmarshal(acc.Registration.Body, &reg.Body); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse stored ACME registration for directory '%s': %s", directoryURL, err)
+		}
 	}
 
-	regData, ok := userData[kubelego.AcmeRegistration]
-	if !ok {
-		return nil, "", fmt.Errorf("could not find an ACME account URI in the account secret")
+	user = &AcmeUser{
+		Email:        acc.Email,
+		key:          privateKey,
+		Registration: reg,
 	}
-	reg := acmeAccountRegistration{}
-	err = json.Unmarshal(regData, &reg)
+
+	client, err = a.newClient(directoryURL, user)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return client, reg.URI, nil
+	return client, user, nil
 }
 
-func (a *Acme) validateUser(client *acme.Client, accountURI string) (account *acme.Account, err error) {
+func (a *Acme) validateUser(client *lego.Client, user *AcmeUser) (reg *registration.Resource, err error) {
 
-	account, err = client.GetReg(context.Background(), accountURI)
+	reg, err = client.Registration.QueryRegistration()
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve ACME account for URI '%s': %s", accountURI, err)
+		return nil, fmt.Errorf("failed to retrieve ACME account for URI '%s': %s", user.Registration.URI, err)
 	}
 
 	contact := a.getContact()
-	if !reflect.DeepEqual(account.Contact, contact) {
-		account.Contact = contact
-		account, err = client.UpdateReg(context.Background(), account)
+	if !reflect.DeepEqual(reg.Body.Contact, contact) {
+		reg, err = client.Registration.UpdateRegistration(registration.RegisterOptions{
+			TermsOfServiceAgreed: true,
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to update ACME account's contact to '%s': %s", contact, err)
 		}
 		a.Log().Infof("updated ACME account's contact to '%s'", contact)
 	}
 
-	return account, nil
+	user.Registration = reg
+	return reg, nil
+}
+
+// parseAccountPrivateKey tries every format generatePrivateKeyPEM has ever
+// produced, so older secrets keep loading.
+func parseAccountPrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ACME account private key as PKCS#1, SEC1 or PKCS#8: %s", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("ACME account private key does not implement crypto.Signer")
+	}
+	return signer, nil
 }
 
+// generatePrivateKey wraps the PEM in a passphrase-encrypted keystoreV3
+// envelope when LEGO_KEY_PASSPHRASE is set; the returned crypto.Signer is
+// always the unwrapped key.
 func (a *Acme) generatePrivateKey() ([]byte, crypto.Signer, error) {
+	privateKeyPem, privateKey, err := a.generatePrivateKeyPEM()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passphrase := a.kubelego.LegoKeyPassphrase()
+	if passphrase == "" {
+		return privateKeyPem, privateKey, nil
+	}
+
+	encrypted, err := encryptPrivateKeyPEM(privateKeyPem, passphrase)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encrypt ACME account key: %s", err)
+	}
+
+	return encrypted, privateKey, nil
+}
+
+func (a *Acme) generatePrivateKeyPEM() ([]byte, crypto.Signer, error) {
 
 	if a.kubelego.LegoKeyType() == kubelego.KeyTypeRsa {
 
@@ -141,30 +268,41 @@ func (a *Acme) generatePrivateKey() ([]byte, crypto.Signer, error) {
 		return pem.EncodeToMemory(block), privateKey, nil
 	}
 
+	if a.kubelego.LegoKeyType() == kubelego.KeyTypeEd25519 {
+
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return []byte{}, nil, err
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return []byte{}, nil, err
+		}
+
+		block := &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+
+		return pem.EncodeToMemory(block), privateKey, nil
+	}
+
 	var ecpk *ecdsa.PrivateKey
 	var err error
 
 	switch a.kubelego.LegoKeySize() {
 	case 224:
 		ecpk, err = ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
-		break
 
 	case 256:
 		ecpk, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-		break
 
-	default:
-		ecpk, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
-		break
-		
 	case 384:
 		ecpk, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
-		break
 
 	case 521:
 		ecpk, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
-		break
 
+	default:
+		return []byte{}, nil, fmt.Errorf("unsupported EC key size '%d'", a.kubelego.LegoKeySize())
 	}
 
 	if err != nil {
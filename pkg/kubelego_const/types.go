@@ -0,0 +1,52 @@
+package kubelego_const
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type KubeLego interface {
+	Log() *logrus.Entry
+
+	LegoEmail() string
+	LegoURL() string
+	LegoKeyType() string
+	LegoKeySize() int
+	LegoKeyPassphrase() string
+	LegoKeyMaxAge() time.Duration
+
+	LegoEabKeyID() string
+	LegoEabHmacKey() string
+
+	AcmeKeyRotationRequested() bool
+
+	AcmeAccountStorageBackend() string
+	AcmeAccountStorageDir() string
+	AcmeVaultAddress() string
+	AcmeVaultToken() string
+	AcmeVaultMountPath() string
+	AcmeVaultPathPrefix() string
+
+	AcmeUser(directoryKey string) (map[string][]byte, error)
+	SaveAcmeUser(directoryKey string, data map[string][]byte) error
+	ListAcmeAccountDirectories() ([]string, error)
+
+	SaveAcmeHttp01Status(domain, token, keyAuth string) error
+	DeleteAcmeHttp01Status(domain, token, keyAuth string) error
+}
+
+const (
+	AcmePrivateKey      = "private-key"
+	AcmeRegistrationUrl = "registration-url"
+	AcmeRegistration    = "registration"
+	AcmeAccount         = "account"
+	AcmeDirectoryUrl    = "directory-url"
+
+	KeyTypeRsa     = "rsa"
+	KeyTypeEd25519 = "ed25519"
+
+	AccountStorageBackendKubernetes = "kubernetes"
+	AccountStorageBackendFilesystem = "filesystem"
+	AccountStorageBackendVault      = "vault"
+)